@@ -0,0 +1,95 @@
+package config
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// Config holds the subset of Traffic Ops's startup configuration that the routing package
+// depends on directly: which routes are Perl/disabled passthroughs, the request timeout
+// default, the cookie-signing secrets, and the routing-adjacent features (structured access
+// logging, rate limiting, tracing) that are opt-in via cdn.conf.
+type Config struct {
+	// Secrets is the list of cookie-signing secrets, newest first; at least one is required,
+	// or startup fails.
+	Secrets []string
+	// RequestTimeout is the default per-route request timeout in seconds, overridden per-route
+	// by Route.Timeout/RawRoute.Timeout.
+	RequestTimeout int
+	// PerlRoutes and DisabledRoutes are route IDs that should be passed through to Perl, or
+	// disabled outright, respectively, instead of being served by their registered Go handler.
+	PerlRoutes     []int
+	DisabledRoutes []int
+
+	// UseStructuredAccessLog selects the JSON access logger over the legacy plain-text one for
+	// every route.
+	UseStructuredAccessLog bool
+
+	// TrustedProxies lists the RemoteAddr host values of the reverse proxy hops actually in
+	// front of this instance (e.g. the load balancer's address). clientIP only trusts
+	// X-Forwarded-For when the request's RemoteAddr is in this list, falling back to RemoteAddr
+	// itself otherwise - this matters most for RateLimitKeyIP, where trusting an unvalidated
+	// client-supplied header would let any caller evade IP-keyed rate limiting by sending a
+	// fresh value on every request.
+	TrustedProxies []string
+
+	// RateLimiting configures the default, global rate limit rules and backend. Zero value
+	// (no rules) disables rate limiting entirely. Declared here rather than as
+	// routing.RateLimitingConfig to avoid a config <-> routing import cycle; routing.go
+	// converts these into routing.RateLimitSpec values at startup.
+	RateLimiting RateLimitingConfig
+
+	// Tracing selects the OpenTelemetry exporter used for request spans.
+	Tracing TracingConfig
+}
+
+// RateLimitingConfig is the cdn.conf section selecting the default rate limit rules and which
+// rate limit backend to use.
+type RateLimitingConfig struct {
+	Rules []RateLimitRule
+	// RedisAddr is accepted, but there is no Redis-backed rate limit backend: a non-empty value
+	// only logs a warning at startup, and the in-memory backend is used either way. That backend
+	// is scoped to this instance only; limits are not shared across multiple Traffic Ops
+	// instances.
+	RedisAddr string
+}
+
+// RateLimitRule is one rate-limiting rule as configured in cdn.conf: RouteIDGlob is matched
+// against "METHOD:path", where path is the route's own declared path, not the full
+// "api/<version>/..." path it's served under (e.g. "POST:snapshot", or "*:deliveryservices/*/capacity"
+// to cover a nested route - a "*" in RouteIDGlob never crosses a "/"). Key selects what the
+// bucket is keyed on ("user", "tenant", or "ip"), and Rate/Burst configure the token bucket.
+// This is the on-the-wire config shape; the routing package converts it to its own
+// RateLimitSpec.
+type RateLimitRule struct {
+	RouteIDGlob string
+	Key         string
+	Rate        float64 // tokens refilled per second
+	Burst       int     // bucket capacity
+}
+
+// TracingConfig is cdn.conf's tracing section, selecting which OpenTelemetry exporter request
+// spans are sent to.
+type TracingConfig struct {
+	// Exporter selects the span exporter: "otlp", "jaeger", or "" (the default) for a no-op
+	// TracerProvider that drops all spans.
+	Exporter string
+	// OTLPEndpoint is the OTLP/HTTP collector endpoint, used when Exporter is "otlp".
+	OTLPEndpoint string
+	// JaegerEndpoint is the Jaeger collector endpoint, used when Exporter is "jaeger".
+	JaegerEndpoint string
+}
@@ -0,0 +1,161 @@
+package routing
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/api"
+)
+
+// structuredAccessLogEntry is the JSON shape emitted by getStructuredAccessLog, one object per
+// request. Fields are omitted rather than zero-valued where the underlying data isn't available
+// (e.g. trace_id/span_id with no active span, user/tenant on unauthenticated routes).
+type structuredAccessLogEntry struct {
+	ReqID      string `json:"req_id"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	RouteID    int    `json:"route_id"`
+	APIVersion string `json:"api_version,omitempty"`
+	User       string `json:"user,omitempty"`
+	Tenant     string `json:"tenant,omitempty"`
+	Status     int    `json:"status"`
+	Bytes      int    `json:"bytes"`
+	DurationMS int64  `json:"duration_ms"`
+	ClientIP   string `json:"client_ip"`
+	TraceID    string `json:"trace_id,omitempty"`
+	SpanID     string `json:"span_id,omitempty"`
+}
+
+// statusCapturingWriter wraps a http.ResponseWriter so the access log can report the status
+// code and body size the handler actually wrote, same as the plain-text access logger does.
+type statusCapturingWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusCapturingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusCapturingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// getStructuredAccessLog returns the JSON-access-log counterpart to getWrapAccessLog: one JSON
+// object per request instead of a free-text line, with the request's OpenTelemetry trace/span
+// IDs attached when TracingMiddleware (which must run before this in the chain) started a span.
+// secret decodes the caller's identity straight from the request's session cookie via
+// resolveRequestUser, so user/tenant are populated regardless of where this middleware sits
+// relative to AuthBase's wrapper in the chain. trustedProxies is forwarded to clientIP.
+func getStructuredAccessLog(secret string, routeID int, trustedProxies []string) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			sw := &statusCapturingWriter{ResponseWriter: w}
+
+			next(sw, r)
+
+			entry := structuredAccessLogEntry{
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				RouteID:    routeID,
+				APIVersion: apiVersionFromPath(r.URL.Path),
+				Status:     sw.status,
+				Bytes:      sw.bytes,
+				DurationMS: time.Since(start).Milliseconds(),
+				ClientIP:   clientIP(r, trustedProxies),
+			}
+			if user, ok := resolveRequestUser(r, secret); ok {
+				entry.User = user.UserName
+				if user.TenantID != 0 {
+					entry.Tenant = strconv.Itoa(user.TenantID)
+				}
+			}
+			if reqID, ok := r.Context().Value(api.ReqIDContextKey).(uint64); ok {
+				entry.ReqID = strconv.FormatUint(reqID, 10)
+			}
+			if spanCtx := trace.SpanContextFromContext(r.Context()); spanCtx.IsValid() {
+				entry.TraceID = spanCtx.TraceID().String()
+				entry.SpanID = spanCtx.SpanID().String()
+			}
+
+			b, err := json.Marshal(entry)
+			if err != nil {
+				log.Errorln("marshalling structured access log entry: " + err.Error())
+				return
+			}
+			log.Infoln(string(b))
+		}
+	}
+}
+
+// clientIP returns the client's address, preferring X-Forwarded-For over the raw RemoteAddr -
+// but only when RemoteAddr itself is one of trustedProxies (the load balancer/reverse proxy
+// hops actually in front of this instance). Any other RemoteAddr gets X-Forwarded-For ignored
+// and RemoteAddr returned as-is, since an untrusted caller can set that header to whatever it
+// likes; honoring it unconditionally would let a client forge a new IP on every request (most
+// exploitable via RateLimitKeyIP, where IP is often the only key available at all).
+func clientIP(r *http.Request, trustedProxies []string) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" && isTrustedProxy(r.RemoteAddr, trustedProxies) {
+		return fwd
+	}
+	return r.RemoteAddr
+}
+
+// isTrustedProxy returns true if remoteAddr's host (its "ip:port" with the port stripped, or
+// the bare value if it has no port) matches one of trustedProxies.
+func isTrustedProxy(remoteAddr string, trustedProxies []string) bool {
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		host = h
+	}
+	for _, proxy := range trustedProxies {
+		if proxy == host {
+			return true
+		}
+	}
+	return false
+}
+
+// apiVersionFromPath returns the version segment of an "/api/<version>/..." request path, or ""
+// if path isn't an API request.
+func apiVersionFromPath(path string) string {
+	segments := splitPath(path)
+	if len(segments) < 2 || !strings.EqualFold(segments[0], "api") {
+		return ""
+	}
+	return segments[1]
+}
@@ -0,0 +1,62 @@
+package routing
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestApiVersionFromPath(t *testing.T) {
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"/api/3.1/deliveryservices", "3.1"},
+		{"api/3.1/deliveryservices", "3.1"},
+		{"/API/2.0/cdns", "2.0"},
+		{"/api", ""},
+		{"/", ""},
+		{"/deliveryservices", ""},
+	}
+	for _, c := range cases {
+		if got := apiVersionFromPath(c.path); got != c.want {
+			t.Errorf("apiVersionFromPath(%q) = %q, want %q", c.path, got, c.want)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/3.1/cdns", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	if ip := clientIP(r, nil); ip != "192.0.2.1:1234" {
+		t.Errorf("expected RemoteAddr fallback, got %q", ip)
+	}
+
+	r.Header.Set("X-Forwarded-For", "203.0.113.5")
+	if ip := clientIP(r, nil); ip != "192.0.2.1:1234" {
+		t.Errorf("expected X-Forwarded-For to be ignored from an untrusted RemoteAddr, got %q", ip)
+	}
+
+	if ip := clientIP(r, []string{"192.0.2.1"}); ip != "203.0.113.5" {
+		t.Errorf("expected X-Forwarded-For to take precedence once RemoteAddr is a trusted proxy, got %q", ip)
+	}
+}
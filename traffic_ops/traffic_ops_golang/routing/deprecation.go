@@ -0,0 +1,212 @@
+package routing
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+)
+
+// successorVersionPath returns the path of the newest minor version sharing route's major
+// version band, for use as the Link: rel="successor-version" target on deprecated routes.
+// remainingVersions is the sorted tail of all registered versions starting at route's own
+// version; nextMajorVer is the first version number that belongs to the next major band.
+func successorVersionPath(remainingVersions []float64, nextMajorVer float64, routePath string) string {
+	latest := 0.0
+	for _, v := range remainingVersions {
+		if v >= nextMajorVer {
+			break
+		}
+		latest = v
+	}
+	vstr := strconv.FormatFloat(latest, 'f', -1, 64)
+	return RoutePrefix[1:] + "/" + vstr + "/" + routePath // strip the regex "^" anchor for a human-facing Link value
+}
+
+// deprecationMiddleware adds the standard deprecation-signaling headers to every response from a
+// route flagged as deprecated via Route.DeprecatedIn: Deprecation always, RFC 8594's Sunset only
+// when a sunset date is set, and a Link: rel="successor-version" only when removedIn is non-zero.
+func deprecationMiddleware(removedIn float64, sunset time.Time, successorPath string) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			if !sunset.IsZero() {
+				w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			if removedIn != 0 {
+				w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successorPath))
+			}
+			next(w, r)
+		}
+	}
+}
+
+// ValidateRouteDeprecations fails startup if any route's RemovedIn version has a major version
+// that is already the current (highest registered) major version, or an earlier one - i.e. a
+// route scheduled for removal "next major" that was never actually bumped out when that major
+// shipped.
+func ValidateRouteDeprecations(rs []Route) error {
+	currentMajor := 0
+	for _, r := range rs {
+		if major := int(r.Version); major > currentMajor {
+			currentMajor = major
+		}
+	}
+	for _, r := range rs {
+		if r.RemovedIn == 0 {
+			continue
+		}
+		if int(r.RemovedIn) <= currentMajor {
+			return fmt.Errorf("route %d (%s %s) has RemovedIn=%.1f, which is already the current major API version (%d); remove the route instead of leaving it flagged", r.ID, r.Method, r.Path, r.RemovedIn, currentMajor)
+		}
+	}
+	return nil
+}
+
+// deprecationCatalogEntry describes one route's deprecation status for the /deprecations
+// endpoint, annotated with a tc.AlertLevel so ops tooling can sort by how urgent the migration
+// is: ErrorLevel once a route is past its sunset date, WarnLevel while it's merely deprecated.
+type deprecationCatalogEntry struct {
+	RouteID      int           `json:"routeId"`
+	Method       string        `json:"method"`
+	Path         string        `json:"path"`
+	DeprecatedIn float64       `json:"deprecatedIn"`
+	RemovedIn    float64       `json:"removedIn,omitempty"`
+	SunsetDate   *time.Time    `json:"sunsetDate,omitempty"`
+	Level        tc.AlertLevel `json:"level"`
+}
+
+// DeprecationsHandler serves the deprecation catalog: every route with a non-zero DeprecatedIn,
+// across all versions it applies to.
+func DeprecationsHandler(rs []Route) http.HandlerFunc {
+	entries := buildDeprecationCatalog(rs)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(struct {
+			Response []deprecationCatalogEntry `json:"response"`
+		}{Response: entries}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func buildDeprecationCatalog(rs []Route) []deprecationCatalogEntry {
+	entries := make([]deprecationCatalogEntry, 0, len(rs))
+	for _, r := range rs {
+		if r.DeprecatedIn == 0 {
+			continue
+		}
+		level := tc.WarnLevel
+		var sunset *time.Time
+		if !r.SunsetDate.IsZero() {
+			sunsetDate := r.SunsetDate
+			sunset = &sunsetDate
+			if time.Now().After(r.SunsetDate) {
+				level = tc.ErrorLevel
+			}
+		}
+		entries = append(entries, deprecationCatalogEntry{
+			RouteID:      r.ID,
+			Method:       r.Method,
+			Path:         r.Path,
+			DeprecatedIn: r.DeprecatedIn,
+			RemovedIn:    r.RemovedIn,
+			SunsetDate:   sunset,
+			Level:        level,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].RouteID < entries[j].RouteID })
+	return entries
+}
+
+// acceptVersionPrefix is the media type prefix clients use to request a specific API version
+// without it appearing in the URL, e.g. "Accept: application/vnd.trafficcontrol.v3+json".
+const acceptVersionPrefix = "application/vnd.trafficcontrol.v"
+const acceptVersionSuffix = "+json"
+
+// negotiateAcceptVersion parses an Accept header for a "application/vnd.trafficcontrol.vN+json"
+// media type and, if present, returns the best registered version matching N: an exact match if
+// one is registered, else the newest registered version within the same major band.
+func negotiateAcceptVersion(accept string, versions map[float64]struct{}) (float64, bool) {
+	for _, mediaType := range strings.Split(accept, ",") {
+		mediaType = strings.TrimSpace(mediaType)
+		if semi := strings.Index(mediaType, ";"); semi >= 0 {
+			mediaType = mediaType[:semi]
+		}
+		if !strings.HasPrefix(mediaType, acceptVersionPrefix) || !strings.HasSuffix(mediaType, acceptVersionSuffix) {
+			continue
+		}
+		vstr := strings.TrimSuffix(strings.TrimPrefix(mediaType, acceptVersionPrefix), acceptVersionSuffix)
+		requested, err := strconv.ParseFloat(vstr, 64)
+		if err != nil {
+			continue
+		}
+		if _, ok := versions[requested]; ok {
+			return requested, true
+		}
+		best, found := 0.0, false
+		for v := range versions {
+			if int(v) == int(requested) && v >= best {
+				best, found = v, true
+			}
+		}
+		if found {
+			return best, true
+		}
+	}
+	return 0, false
+}
+
+// negotiateVersionedPath rewrites requested (a path with its leading '/' already stripped) to
+// target the version negotiated from the request's Accept header - inserting it when requested
+// omits a version segment entirely (the case this feature exists for: "/api/deliveryservices"
+// rather than requiring "/api/3.1/deliveryservices" in the URL), or replacing it when requested
+// already names one. Returns false if the request carries no usable version negotiation header,
+// or if requested isn't already addressed at "/api/..." - negotiation only relaxes the version
+// requirement on API requests, it must never reroute arbitrary non-API traffic into the API
+// namespace just because it happens to carry a vendor Accept header.
+func negotiateVersionedPath(r *http.Request, requested string, versions map[float64]struct{}) (string, bool) {
+	segments := splitPath(requested)
+	if len(segments) == 0 || strings.ToLower(segments[0]) != "api" {
+		return "", false
+	}
+	version, ok := negotiateAcceptVersion(r.Header.Get("Accept"), versions)
+	if !ok {
+		return "", false
+	}
+	vstr := strconv.FormatFloat(version, 'f', -1, 64)
+	if len(segments) > 1 {
+		if _, err := strconv.ParseFloat(segments[1], 64); err == nil {
+			// segments[1] already names a version (even if unregistered) - replace it.
+			segments[1] = vstr
+			return strings.Join(segments, "/"), true
+		}
+	}
+	// No version segment present at all - insert one rather than overwriting the resource path.
+	segments = append(segments[:1], append([]string{vstr}, segments[1:]...)...)
+	return strings.Join(segments, "/"), true
+}
@@ -0,0 +1,127 @@
+package routing
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-tc"
+)
+
+func TestValidateRouteDeprecations(t *testing.T) {
+	// RemovedIn's major version is still in the future relative to the current major (3) - ok.
+	if err := ValidateRouteDeprecations([]Route{
+		{ID: 1, Version: 3.1, RemovedIn: 4.0},
+	}); err != nil {
+		t.Errorf("expected no error for a RemovedIn beyond the current major, got %v", err)
+	}
+
+	// RemovedIn's major version is exactly the current major - the boundary this check exists
+	// for: the route was supposed to be removed by now and wasn't.
+	if err := ValidateRouteDeprecations([]Route{
+		{ID: 2, Version: 3.1, RemovedIn: 3.0},
+	}); err == nil {
+		t.Error("expected an error when RemovedIn's major version equals the current major")
+	}
+
+	// RemovedIn unset entirely is never an error, regardless of version.
+	if err := ValidateRouteDeprecations([]Route{
+		{ID: 3, Version: 3.1},
+	}); err != nil {
+		t.Errorf("expected no error for a route with no RemovedIn set, got %v", err)
+	}
+}
+
+func TestBuildDeprecationCatalog(t *testing.T) {
+	routes := []Route{
+		{ID: 2, Method: http.MethodGet, Path: "cdns", DeprecatedIn: 2.0, RemovedIn: 3.0, SunsetDate: time.Now().Add(-time.Hour)},
+		{ID: 1, Method: http.MethodGet, Path: "servers", DeprecatedIn: 2.0},
+		{ID: 3, Method: http.MethodGet, Path: "divisions"},
+	}
+
+	entries := buildDeprecationCatalog(routes)
+	if len(entries) != 2 {
+		t.Fatalf("expected only the two deprecated routes in the catalog, got %d", len(entries))
+	}
+	if entries[0].RouteID != 1 || entries[1].RouteID != 2 {
+		t.Errorf("expected entries sorted by RouteID, got %d then %d", entries[0].RouteID, entries[1].RouteID)
+	}
+	if entries[1].Level != tc.ErrorLevel {
+		t.Errorf("expected a route past its sunset date to be ErrorLevel, got %v", entries[1].Level)
+	}
+	if entries[0].Level != tc.WarnLevel {
+		t.Errorf("expected a route with no sunset date to be WarnLevel, got %v", entries[0].Level)
+	}
+}
+
+func TestNegotiateAcceptVersion(t *testing.T) {
+	versions := map[float64]struct{}{1.1: {}, 1.2: {}, 2.0: {}}
+
+	if v, ok := negotiateAcceptVersion("application/vnd.trafficcontrol.v1.1+json", versions); !ok || v != 1.1 {
+		t.Errorf("expected an exact match to win, got (%v, %v)", v, ok)
+	}
+
+	// No exact 1.0 registered - falls back to the newest version within major band 1.
+	if v, ok := negotiateAcceptVersion("application/vnd.trafficcontrol.v1+json", versions); !ok || v != 1.2 {
+		t.Errorf("expected best-in-major fallback to 1.2, got (%v, %v)", v, ok)
+	}
+
+	if _, ok := negotiateAcceptVersion("application/vnd.trafficcontrol.v9+json", versions); ok {
+		t.Error("expected no match for a major version with nothing registered")
+	}
+
+	if _, ok := negotiateAcceptVersion("application/json", versions); ok {
+		t.Error("expected no match for an Accept header without the versioned media type")
+	}
+}
+
+func TestNegotiateVersionedPath(t *testing.T) {
+	versions := map[float64]struct{}{3.1: {}}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/deliveryservices", nil)
+	r.Header.Set("Accept", "application/vnd.trafficcontrol.v3+json")
+	path, ok := negotiateVersionedPath(r, "api/deliveryservices", versions)
+	if !ok || path != "api/3.1/deliveryservices" {
+		t.Errorf("expected a version to be inserted, got (%q, %v)", path, ok)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/api/2.0/deliveryservices", nil)
+	r.Header.Set("Accept", "application/vnd.trafficcontrol.v3+json")
+	path, ok = negotiateVersionedPath(r, "api/2.0/deliveryservices", versions)
+	if !ok || path != "api/3.1/deliveryservices" {
+		t.Errorf("expected the existing version segment to be replaced, got (%q, %v)", path, ok)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/api/deliveryservices", nil)
+	path, ok = negotiateVersionedPath(r, "api/deliveryservices", versions)
+	if ok {
+		t.Errorf("expected no negotiation without an Accept header, got (%q, %v)", path, ok)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/deliveryservices/42", nil)
+	r.Header.Set("Accept", "application/vnd.trafficcontrol.v3+json")
+	path, ok = negotiateVersionedPath(r, "deliveryservices/42", versions)
+	if ok {
+		t.Errorf("expected no rewrite for a non-/api path even with a vendor Accept header, got (%q, %v)", path, ok)
+	}
+}
@@ -0,0 +1,48 @@
+package routing
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"net/http"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/auth"
+)
+
+// mojoliciousCookieName is the signed session cookie the plain-text access logger and the Perl
+// handlers both read the current user from.
+const mojoliciousCookieName = "mojolicious"
+
+// resolveRequestUser decodes the authenticated user directly from the request's signed session
+// cookie using secret, the same way the plain-text access logger identifies the caller. Doing
+// the decode locally - rather than reading a value AuthBase's wrapper stashes in r.Context() -
+// means callers don't need to run after that wrapper in the middleware chain to see who's
+// making the request. Returns false if the request carries no valid session, in which case
+// callers should treat the request as unauthenticated.
+func resolveRequestUser(r *http.Request, secret string) (auth.CurrentUser, bool) {
+	cookie, err := r.Cookie(mojoliciousCookieName)
+	if err != nil {
+		return auth.CurrentUser{}, false
+	}
+	user, err := auth.DecodeCookie(cookie.Value, secret)
+	if err != nil {
+		return auth.CurrentUser{}, false
+	}
+	return user, true
+}
@@ -0,0 +1,237 @@
+package routing
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apache/trafficcontrol/lib/go-log"
+	"github.com/apache/trafficcontrol/lib/go-tc"
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+)
+
+// RateLimitKeyType selects what a RateLimitSpec's token bucket is keyed on.
+type RateLimitKeyType string
+
+const (
+	RateLimitKeyUser   RateLimitKeyType = "user"
+	RateLimitKeyTenant RateLimitKeyType = "tenant"
+	RateLimitKeyIP     RateLimitKeyType = "ip"
+)
+
+// RateLimitSpec is one rate-limiting rule: RouteIDGlob is matched against "METHOD:path" using
+// path.Match, where path is the route's own declared Path - not the full "api/<version>/..."
+// path it's served under, since the same rule should apply uniformly across every minor version
+// of a route (e.g. "POST:snapshot", not "POST:api/*/snapshot"). Key selects what the bucket is
+// keyed on, and Rate/Burst configure the token bucket. A Route's own RateLimit field takes
+// precedence over any global rule matching the same route.
+//
+// Because matching goes through path.Match, a "*" in RouteIDGlob never crosses a "/": it covers
+// exactly one path segment, not an arbitrary-depth subtree. A rule meant to cover every nested
+// route under a resource has to glob one "*" per segment it needs to reach (e.g.
+// "*:deliveryservices/*/capacity", not "*:deliveryservices*") - a trailing "*" with no segment
+// boundary silently never matches any path with an extra segment, which for a rate limit reads
+// as "covered" while actually never applying.
+type RateLimitSpec struct {
+	RouteIDGlob string
+	Key         RateLimitKeyType
+	Rate        float64 // tokens refilled per second
+	Burst       int     // bucket capacity
+}
+
+// RateLimitBackend is the pluggable token-bucket store behind the RateLimit middleware. The
+// default, InMemoryRateLimitBackend, is scoped to a single Traffic Ops instance; a Redis-backed
+// implementation lets multiple instances behind a load balancer share the same limits.
+type RateLimitBackend interface {
+	// Allow consumes one token for key under the given rate/burst. It returns whether the
+	// request is allowed, how many tokens remain in the bucket, and - when not allowed - how
+	// long the caller should wait before retrying.
+	Allow(key string, rate float64, burst int) (allowed bool, remaining int, retryAfter time.Duration)
+}
+
+// tokenBucket is a single key's bucket: tokens refill continuously at rate per second, up to
+// capacity, and each allowed request consumes one.
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	rate     float64
+	last     time.Time
+}
+
+func (b *tokenBucket) take() (bool, int, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if b.rate > 0 {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		if b.rate <= 0 {
+			// A non-positive Rate is a misconfiguration; guard it here rather than dividing by
+			// zero, which would turn retryAfter into a Duration
+			// derived from +Inf/NaN.
+			return false, 0, 0
+		}
+		retryAfter := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		return false, 0, retryAfter
+	}
+	b.tokens--
+	return true, int(b.tokens), 0
+}
+
+// InMemoryRateLimitBackend is the default RateLimitBackend: one token bucket per key, held in
+// process memory. It does not coordinate across multiple Traffic Ops instances.
+type InMemoryRateLimitBackend struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// NewInMemoryRateLimitBackend returns an empty InMemoryRateLimitBackend.
+func NewInMemoryRateLimitBackend() *InMemoryRateLimitBackend {
+	return &InMemoryRateLimitBackend{buckets: map[string]*tokenBucket{}}
+}
+
+// Allow implements RateLimitBackend.
+func (be *InMemoryRateLimitBackend) Allow(key string, rate float64, burst int) (bool, int, time.Duration) {
+	be.mu.Lock()
+	b, ok := be.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), capacity: float64(burst), rate: rate, last: time.Now()}
+		be.buckets[key] = b
+	}
+	be.mu.Unlock()
+	return b.take()
+}
+
+// newRateLimitBackend builds the RateLimitBackend selected by cfg, or nil if rate limiting isn't
+// configured at all. TODO: a Redis-backed RateLimitBackend implementation, selected when
+// cfg.RedisAddr is set; until then RedisAddr is accepted but falls back to in-memory, which logs
+// a warning since an operator setting it almost certainly wants limits shared across instances
+// rather than silently getting independent per-instance ones.
+func newRateLimitBackend(cfg config.RateLimitingConfig) RateLimitBackend {
+	if len(cfg.Rules) == 0 {
+		return nil
+	}
+	if cfg.RedisAddr != "" {
+		log.Warnln("rate limiting: RedisAddr is set but a Redis-backed RateLimitBackend isn't implemented yet; falling back to the in-memory backend, which does NOT share limits across multiple Traffic Ops instances")
+	}
+	return NewInMemoryRateLimitBackend()
+}
+
+// rateLimitSpecsFromConfig converts the cdn.conf rate limit rules into RateLimitSpecs.
+func rateLimitSpecsFromConfig(rules []config.RateLimitRule) []RateLimitSpec {
+	specs := make([]RateLimitSpec, len(rules))
+	for i, rule := range rules {
+		specs[i] = RateLimitSpec{
+			RouteIDGlob: rule.RouteIDGlob,
+			Key:         RateLimitKeyType(rule.Key),
+			Rate:        rule.Rate,
+			Burst:       rule.Burst,
+		}
+	}
+	return specs
+}
+
+// rateLimitKeyFor resolves the bucket key for a request under the given RateLimitKeyType,
+// falling back to the client's address whenever the requested dimension isn't available (e.g.
+// "user" on a request that carries no valid session). secret decodes the request's session
+// cookie directly via resolveRequestUser, so this works regardless of where rate limiting sits
+// relative to AuthBase's wrapper in the middleware chain. trustedProxies is forwarded to
+// clientIP so RateLimitKeyIP can't be defeated by a client forging its own X-Forwarded-For.
+func rateLimitKeyFor(r *http.Request, keyType RateLimitKeyType, secret string, trustedProxies []string) string {
+	switch keyType {
+	case RateLimitKeyUser:
+		if user, ok := resolveRequestUser(r, secret); ok && user.UserName != "" {
+			return "user:" + user.UserName
+		}
+	case RateLimitKeyTenant:
+		if user, ok := resolveRequestUser(r, secret); ok && user.TenantID != 0 {
+			return "tenant:" + strconv.Itoa(user.TenantID)
+		}
+	}
+	return "ip:" + clientIP(r, trustedProxies)
+}
+
+// matchesRoute returns true if spec applies to the given method and route's own declared path
+// (e.g. "snapshot", not "api/3.1/snapshot" - see RateLimitSpec's doc comment).
+func (spec RateLimitSpec) matchesRoute(method, routePath string) bool {
+	ok, err := path.Match(spec.RouteIDGlob, method+":"+routePath)
+	return err == nil && ok
+}
+
+// resolveRateLimit returns the RateLimitSpec that should apply to a route, preferring its own
+// override over the first matching global rule. It returns false if no rule applies.
+func resolveRateLimit(routeOverride *RateLimitSpec, globalRules []RateLimitSpec, method, routePath string) (RateLimitSpec, bool) {
+	if routeOverride != nil {
+		return *routeOverride, true
+	}
+	for _, rule := range globalRules {
+		if rule.matchesRoute(method, routePath) {
+			return rule, true
+		}
+	}
+	return RateLimitSpec{}, false
+}
+
+// RateLimit returns a Middleware enforcing spec's token-bucket limit against backend, keyed by
+// the resolved user/tenant/IP. secret is used to resolve the caller's identity for
+// RateLimitKeyUser/RateLimitKeyTenant rules; see rateLimitKeyFor. trustedProxies is forwarded to
+// rateLimitKeyFor so RateLimitKeyIP trusts X-Forwarded-For only from a configured reverse proxy.
+// Rejected requests get a 429 with a tc.Alert body at ErrorLevel, plus Retry-After and
+// X-RateLimit-Remaining headers; allowed requests still get X-RateLimit-Remaining so clients can
+// back off proactively.
+func RateLimit(backend RateLimitBackend, spec RateLimitSpec, secret string, trustedProxies []string) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			key := rateLimitKeyFor(r, spec.Key, secret, trustedProxies)
+			// The bucket key includes the rule itself, so routes governed by different rules (or by
+			// a route's own override) draw from separate buckets instead of sharing one whose
+			// rate/burst was fixed by whichever rule first created it for this user/tenant/IP.
+			bucketKey := spec.RouteIDGlob + "|" + strconv.FormatFloat(spec.Rate, 'f', -1, 64) + "/" + strconv.Itoa(spec.Burst) + "|" + string(spec.Key) + ":" + key
+			allowed, remaining, retryAfter := backend.Allow(bucketKey, spec.Rate, spec.Burst)
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+			if allowed {
+				next(w, r)
+				return
+			}
+
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			alert := tc.Alert{Level: tc.ErrorLevel, Text: "rate limit exceeded, retry after " + retryAfter.Round(time.Second).String()}
+			json.NewEncoder(w).Encode(struct {
+				Alerts []tc.Alert `json:"alerts"`
+			}{Alerts: []tc.Alert{alert}})
+		}
+	}
+}
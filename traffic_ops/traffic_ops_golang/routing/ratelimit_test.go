@@ -0,0 +1,134 @@
+package routing
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketTake(t *testing.T) {
+	b := &tokenBucket{tokens: 2, capacity: 2, rate: 1, last: time.Now()}
+
+	allowed, remaining, retryAfter := b.take()
+	if !allowed || remaining != 1 || retryAfter != 0 {
+		t.Fatalf("first take: got (%v, %d, %v), want (true, 1, 0)", allowed, remaining, retryAfter)
+	}
+
+	allowed, remaining, retryAfter = b.take()
+	if !allowed || remaining != 0 || retryAfter != 0 {
+		t.Fatalf("second take: got (%v, %d, %v), want (true, 0, 0)", allowed, remaining, retryAfter)
+	}
+
+	allowed, _, retryAfter = b.take()
+	if allowed {
+		t.Fatal("third take: expected bucket to be empty, but it was allowed")
+	}
+	if retryAfter <= 0 {
+		t.Errorf("expected a positive retryAfter once the bucket is empty, got %v", retryAfter)
+	}
+}
+
+// TestTokenBucketTakeZeroRate guards against a Rate<=0 misconfiguration dividing by zero in the
+// retryAfter calculation, which produces a Duration derived from +Inf/NaN instead of a sane value.
+func TestTokenBucketTakeZeroRate(t *testing.T) {
+	b := &tokenBucket{tokens: 0, capacity: 1, rate: 0, last: time.Now()}
+
+	allowed, remaining, retryAfter := b.take()
+	if allowed {
+		t.Fatal("expected an empty, non-refilling bucket to deny the request")
+	}
+	if remaining != 0 {
+		t.Errorf("expected remaining=0, got %d", remaining)
+	}
+	if retryAfter != 0 {
+		t.Errorf("expected retryAfter=0 for a Rate<=0 bucket, got %v", retryAfter)
+	}
+}
+
+func TestRateLimitSpecMatchesRoute(t *testing.T) {
+	spec := RateLimitSpec{RouteIDGlob: "POST:snapshot"}
+	if !spec.matchesRoute("POST", "snapshot") {
+		t.Error("expected an exact method/path glob to match")
+	}
+	if spec.matchesRoute("GET", "snapshot") {
+		t.Error("expected a mismatched method not to match")
+	}
+	if spec.matchesRoute("POST", "cdns/1/snapshot") {
+		t.Error("expected a mismatched path not to match")
+	}
+
+	// RouteIDGlob is matched with path.Match, whose "*" never crosses a "/" - so a trailing "*"
+	// only covers the rest of the *current* path segment, not an entire subtree. A rule meant to
+	// cover every nested "deliveryservices/..." route has to glob each segment it needs to reach,
+	// the same way "POST:cdns/*/queue_update" globs exactly one segment.
+	wildcard := RateLimitSpec{RouteIDGlob: "*:deliveryservices*"}
+	if !wildcard.matchesRoute("GET", "deliveryservices") {
+		t.Error("expected a glob with a wildcard method and same-segment path suffix to match")
+	}
+	if wildcard.matchesRoute("GET", "deliveryservices/1") {
+		t.Error("expected a trailing \"*\" not to match a path with an extra segment - path.Match's \"*\" doesn't cross \"/\"")
+	}
+
+	nested := RateLimitSpec{RouteIDGlob: "*:deliveryservices/*"}
+	if !nested.matchesRoute("GET", "deliveryservices/1") {
+		t.Error("expected a glob with one wildcard segment per path segment to match")
+	}
+	if nested.matchesRoute("GET", "deliveryservices/1/capacity") {
+		t.Error("expected a single trailing segment wildcard not to match a deeper nested path")
+	}
+}
+
+func TestResolveRateLimit(t *testing.T) {
+	global := []RateLimitSpec{{RouteIDGlob: "POST:snapshot", Rate: 1, Burst: 1}}
+
+	if _, ok := resolveRateLimit(nil, global, "GET", "cdns"); ok {
+		t.Error("expected no global rule to match an unrelated route")
+	}
+
+	spec, ok := resolveRateLimit(nil, global, "POST", "snapshot")
+	if !ok || spec.Rate != 1 {
+		t.Fatalf("expected the global rule to apply, got (%+v, %v)", spec, ok)
+	}
+
+	override := &RateLimitSpec{RouteIDGlob: "POST:snapshot", Rate: 5, Burst: 5}
+	spec, ok = resolveRateLimit(override, global, "POST", "snapshot")
+	if !ok || spec.Rate != 5 {
+		t.Fatalf("expected the route's own override to win over the global rule, got (%+v, %v)", spec, ok)
+	}
+}
+
+// TestRateLimitKeyForIPRequiresTrustedProxy guards against a client defeating RateLimitKeyIP by
+// forging a fresh X-Forwarded-For on every request: the header should only be honored once
+// RemoteAddr is a configured trusted proxy.
+func TestRateLimitKeyForIPRequiresTrustedProxy(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/3.1/cdns", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	r.Header.Set("X-Forwarded-For", "203.0.113.5")
+
+	if key := rateLimitKeyFor(r, RateLimitKeyIP, "secret", nil); key != "ip:192.0.2.1:1234" {
+		t.Errorf("expected an untrusted RemoteAddr's X-Forwarded-For to be ignored, got %q", key)
+	}
+	if key := rateLimitKeyFor(r, RateLimitKeyIP, "secret", []string{"192.0.2.1"}); key != "ip:203.0.113.5" {
+		t.Errorf("expected X-Forwarded-For to be honored once RemoteAddr is a trusted proxy, got %q", key)
+	}
+}
@@ -0,0 +1,222 @@
+package routing
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import "strings"
+
+// routeTrieNode is one path segment of a routeTrie. Literal segments are looked up by exact
+// string match in children; a `{param}` segment is represented by the single wildcard child,
+// shared by every route that has a param at that position, which is what lets e.g. every
+// nested "{id}" sub-resource share one walk through the trie instead of being scanned as N
+// separate regexes.
+//
+// The API version segment (index 1, right after "api") also walks the same shared wildcard
+// child, rather than each version getting its own literal "1.1", "1.2", ... child - that's what
+// actually shares the "api/{v}/..." prefix across versions, since CreateRouteMap still
+// duplicates a Route once per minor version it applies to. It has to be the same wildcard used
+// by `{param}` segments generally, not a separate field: a RawRoute like "api/{v}/deprecations"
+// legitimately declares its own version segment as a `{v}` param and is inserted through that
+// exact node, so a dedicated "versionChild" preferred unconditionally over wildcard would make
+// such routes unreachable the moment any ordinary versioned route shared the same "api" node.
+// The specific CompiledRoute for a literal version is resolved at the leaf, via routesByVersion,
+// once the rest of the path has matched - this also means a request naming a version the route
+// was never duplicated into correctly fails to match instead of being served by whatever version
+// happened to be inserted last. A leaf reached this way has routesByVersion set; a leaf reached
+// via a genuine `{v}` param route (no per-version duplication) has route set instead.
+type routeTrieNode struct {
+	children        map[string]*routeTrieNode
+	wildcard        *routeTrieNode
+	route           *CompiledRoute            // non-nil on a leaf reached without per-version duplication
+	routesByVersion map[string]*CompiledRoute // non-nil on a leaf reached through a duplicated version segment, keyed by the literal version (e.g. "1.3")
+}
+
+func newRouteTrieNode() *routeTrieNode {
+	return &routeTrieNode{children: map[string]*routeTrieNode{}}
+}
+
+// routeTrie dispatches a request path to its CompiledRoute in time proportional to the number
+// of path segments, rather than the number of registered routes.
+type routeTrie struct {
+	root *routeTrieNode
+}
+
+func newRouteTrie() *routeTrie {
+	return &routeTrie{root: newRouteTrieNode()}
+}
+
+// isParamSegment returns true if segment is a `{name}` path template placeholder.
+func isParamSegment(segment string) bool {
+	return len(segment) > 1 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// splitPath splits a '/'-separated request or route path into segments, ignoring leading,
+// trailing, and repeated slashes so "/api/3.0/cdns/" and "api/3.0/cdns" walk identically.
+func splitPath(path string) []string {
+	parts := strings.Split(path, "/")
+	segments := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p != "" {
+			segments = append(segments, p)
+		}
+	}
+	return segments
+}
+
+// isVersionedPath returns true if segments is a path starting with the literal "api" segment,
+// i.e. one CreateRouteMap duplicates per minor version rather than registering once.
+func isVersionedPath(segments []string) bool {
+	return len(segments) > 0 && strings.EqualFold(segments[0], "api")
+}
+
+// insert adds route, indexed by its own Path, into the trie. Routes that share a prefix - most
+// notably the API version segment, duplicated by CreateRouteMap once per minor version in a
+// major band - share the same chain of nodes: index 1 of a versioned path always walks the
+// shared wildcard child, the same one any `{param}` segment would, rather than a per-version
+// literal child, so e.g. every version of "api/{v}/deliveryservices/{id}/..." is one subtree,
+// not one subtree per version. Whether the version itself was a literal (duplicated per
+// version) or a genuine `{v}` param (a RawRoute inserted once) is recorded only at the leaf -
+// the former in routesByVersion, so a request can still be rejected for naming a version the
+// route wasn't duplicated into, the latter in route, same as any other param route.
+func (t *routeTrie) insert(route CompiledRoute) {
+	segments := splitPath(route.Path)
+	versioned := isVersionedPath(segments) && len(segments) > 1 && !isParamSegment(segments[1])
+	node := t.root
+	for i, segment := range segments {
+		if i == 1 && isVersionedPath(segments) {
+			if node.wildcard == nil {
+				node.wildcard = newRouteTrieNode()
+			}
+			node = node.wildcard
+			continue
+		}
+		if isParamSegment(segment) {
+			if node.wildcard == nil {
+				node.wildcard = newRouteTrieNode()
+			}
+			node = node.wildcard
+			continue
+		}
+		child, ok := node.children[segment]
+		if !ok {
+			child = newRouteTrieNode()
+			node.children[segment] = child
+		}
+		node = child
+	}
+	r := route
+	if versioned {
+		if node.routesByVersion == nil {
+			node.routesByVersion = map[string]*CompiledRoute{}
+		}
+		node.routesByVersion[segments[1]] = &r
+		return
+	}
+	node.route = &r
+}
+
+// match walks the trie for the given request path, preferring a literal child over the
+// wildcard at every segment so a more specific route (e.g. "deliveryservices/capacity") wins
+// over a same-depth param route (e.g. "deliveryservices/{id}"). That preference is only a first
+// try, not a commitment: choosing the literal child can walk into a subtree that has no route
+// registered for the remaining segments, while the sibling wildcard the literal shadowed would
+// have matched. matchTrieNode backtracks to the wildcard (and no further - a request path only
+// has one segment at each depth, so there's nothing else to try) whenever the literal branch
+// dead-ends, so e.g. inserting both "deliveryservices/{id}/capacity" and
+// "deliveryservices/capacity/types" still resolves "deliveryservices/capacity/capacity" to the
+// former with id="capacity", rather than failing just because "capacity" is also a literal
+// sibling one level up. On a match, params is reset and repopulated with the captured `{param}`
+// values keyed by name; the caller is expected to pass in the same map across requests to avoid
+// allocating one per request.
+//
+// Index 1 of a versioned path is captured separately from the rest, rather than folded straight
+// into captured, because the two leaf shapes it can resolve to disagree on what it means: a leaf
+// populated via routesByVersion (an ordinary route CreateRouteMap duplicated per version) has no
+// param named for it, while a leaf populated via route directly (a RawRoute declaring its own
+// `{v}` param) expects it as its first captured value. Which one applies isn't known until the
+// rest of the path has matched, since both shapes can hang off the very same "api" wildcard node.
+func (t *routeTrie) match(path string, params map[string]string) *CompiledRoute {
+	segments := splitPath(path)
+	versioned := isVersionedPath(segments)
+	route, paramValues := matchTrieNode(t.root, segments, 0, versioned, nil, "")
+	if route == nil {
+		return nil
+	}
+
+	for k := range params {
+		delete(params, k)
+	}
+	for i, name := range route.Params {
+		if i < len(paramValues) {
+			params[name] = paramValues[i]
+		}
+	}
+	return route
+}
+
+// matchTrieNode walks segments[i:] from node, returning the resolved CompiledRoute and its
+// captured param values, or (nil, nil) if no route - literal or wildcard - covers this path.
+// versionSegment is threaded through unset ("") until index 1 sets it, then carried to the leaf
+// so a versioned leaf can resolve routesByVersion once the rest of the path has matched.
+func matchTrieNode(node *routeTrieNode, segments []string, i int, versioned bool, captured []string, versionSegment string) (*CompiledRoute, []string) {
+	if i == len(segments) {
+		if versioned && len(segments) > 1 {
+			if r, ok := node.routesByVersion[versionSegment]; ok {
+				return r, captured
+			}
+			if node.route != nil {
+				return node.route, append([]string{versionSegment}, captured...)
+			}
+			return nil, nil
+		}
+		return node.route, captured
+	}
+
+	segment := segments[i]
+	if i == 1 && versioned {
+		if node.wildcard == nil {
+			return nil, nil
+		}
+		return matchTrieNode(node.wildcard, segments, i+1, versioned, captured, segment)
+	}
+
+	if child, ok := node.children[segment]; ok {
+		if route, paramValues := matchTrieNode(child, segments, i+1, versioned, captured, versionSegment); route != nil {
+			return route, paramValues
+		}
+	}
+	if node.wildcard == nil {
+		return nil, nil
+	}
+	nextCaptured := append(append([]string{}, captured...), segment)
+	return matchTrieNode(node.wildcard, segments, i+1, versioned, nextCaptured, versionSegment)
+}
+
+// buildRouteTries indexes every method's CompiledRoutes into its own routeTrie.
+func buildRouteTries(compiledRoutes map[string][]CompiledRoute) map[string]*routeTrie {
+	tries := make(map[string]*routeTrie, len(compiledRoutes))
+	for method, routes := range compiledRoutes {
+		trie := newRouteTrie()
+		for _, route := range routes {
+			trie.insert(route)
+		}
+		tries[method] = trie
+	}
+	return tries
+}
@@ -0,0 +1,246 @@
+package routing
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testRouteResources are a handful of resource names repeated across versions, loosely modeled
+// on the real route table, so the generated benchmark set exercises shared prefixes the way
+// "deliveryservices", "servers", etc. do in production.
+var testRouteResources = []string{
+	"cdns", "deliveryservices", "servers", "profiles", "parameters", "cachegroups",
+	"divisions", "regions", "statuses", "types", "users", "jobs", "asns", "coordinates",
+	"physlocations", "staticdnsentries", "federations", "roles", "capabilities", "topologies",
+}
+
+// buildTestCompiledRoutes builds a realistic-ish route set (~500 routes across 6 versions) for
+// the benchmarks below: each resource gets a collection, an {id} route, a nested sub-resource
+// collection, and a nested {capID} route, duplicated per version the way CreateRouteMap duplicates a Route across
+// its minor version band.
+func buildTestCompiledRoutes() []CompiledRoute {
+	versions := []string{"1.1", "1.2", "1.3", "1.4", "1.5", "2.0"}
+	var routes []CompiledRoute
+	noopHandler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	for _, v := range versions {
+		for _, resource := range testRouteResources {
+			collection := fmt.Sprintf("api/%s/%s", v, resource)
+			byID := collection + "/{id}"
+			nested := byID + "/capabilities/{capID}"
+			routes = append(routes,
+				CompiledRoute{Handler: noopHandler, Params: nil, Path: collection},
+				CompiledRoute{Handler: noopHandler, Params: []string{"id"}, Path: byID},
+				CompiledRoute{Handler: noopHandler, Params: []string{"id"}, Path: byID + "/capabilities"},
+				CompiledRoute{Handler: noopHandler, Params: []string{"id", "capID"}, Path: nested},
+			)
+		}
+	}
+	return routes
+}
+
+func buildTestTrie(routes []CompiledRoute) *routeTrie {
+	trie := newRouteTrie()
+	for _, r := range routes {
+		trie.insert(r)
+	}
+	return trie
+}
+
+// regexScanMatch replicates the pre-trie linear regex scan, for benchmark comparison only.
+func regexScanMatch(routes []CompiledRoute, path string) *CompiledRoute {
+	for i := range routes {
+		if match := routes[i].Regex.FindStringSubmatch(path); len(match) > 0 {
+			return &routes[i]
+		}
+	}
+	return nil
+}
+
+func compileRegexes(routes []CompiledRoute) []CompiledRoute {
+	compiled := make([]CompiledRoute, len(routes))
+	for i, r := range routes {
+		route := "^" + r.Path
+		for _, p := range r.Params {
+			route = strings.Replace(route, "{"+p+"}", `([^/]+)`, 1)
+		}
+		compiled[i] = CompiledRoute{Handler: r.Handler, Params: r.Params, Path: r.Path, Regex: regexp.MustCompile(route)}
+	}
+	return compiled
+}
+
+func TestRouteTrieMatch(t *testing.T) {
+	routes := buildTestCompiledRoutes()
+	trie := buildTestTrie(routes)
+
+	params := map[string]string{}
+	match := trie.match("api/1.3/deliveryservices/42", params)
+	if match == nil {
+		t.Fatal("expected a match for a known route, got nil")
+	}
+	if params["id"] != "42" {
+		t.Errorf("expected captured param id=42, got %q", params["id"])
+	}
+
+	match = trie.match("api/1.3/deliveryservices", params)
+	if match == nil {
+		t.Fatal("expected a match for the collection route, got nil")
+	}
+
+	if match := trie.match("api/1.3/does-not-exist", params); match != nil {
+		t.Errorf("expected no match for unknown path, got %+v", match)
+	}
+}
+
+// TestRouteTrieMatchRawVersionRoute guards against a regression where a RawRoute's own `{v}`
+// param (e.g. "api/{v}/deprecations") became unreachable once any ordinary per-version-duplicated
+// route shared the same "api" node: match() used to prefer the version-duplication leaf shape
+// unconditionally, so the raw route's differently-shaped leaf was never consulted.
+func TestRouteTrieMatchRawVersionRoute(t *testing.T) {
+	routes := buildTestCompiledRoutes()
+	noopHandler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	routes = append(routes, CompiledRoute{Handler: noopHandler, Params: []string{"v"}, Path: "api/{v}/deprecations"})
+	trie := buildTestTrie(routes)
+
+	params := map[string]string{}
+	match := trie.match("api/3.1/deprecations", params)
+	if match == nil {
+		t.Fatal("expected the raw api/{v}/deprecations route to match despite sharing its \"api\" node with versioned routes")
+	}
+	if params["v"] != "3.1" {
+		t.Errorf("expected captured param v=3.1, got %q", params["v"])
+	}
+
+	// An ordinary versioned route sharing the same node must still resolve correctly too.
+	match = trie.match("api/1.3/deliveryservices/42", params)
+	if match == nil {
+		t.Fatal("expected a normal versioned route to still match alongside a raw {v} route")
+	}
+	if params["id"] != "42" {
+		t.Errorf("expected captured param id=42, got %q", params["id"])
+	}
+}
+
+// TestRouteTrieMatchBacktracksPastLiteralDeadEnd guards against a regression where match() chose
+// the literal child over the wildcard at a segment and never reconsidered, so a literal sibling
+// that happened to dead-end deeper in the tree caused the whole match to fail instead of falling
+// back to the wildcard route it shadowed.
+func TestRouteTrieMatchBacktracksPastLiteralDeadEnd(t *testing.T) {
+	noopHandler := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	trie := buildTestTrie([]CompiledRoute{
+		{Handler: noopHandler, Params: []string{"id"}, Path: "api/3.0/deliveryservices/{id}/capacity"},
+		{Handler: noopHandler, Params: nil, Path: "api/3.0/deliveryservices/capacity/types"},
+	})
+
+	params := map[string]string{}
+	match := trie.match("api/3.0/deliveryservices/capacity/capacity", params)
+	if match == nil {
+		t.Fatal("expected backtracking to the {id} wildcard route, got nil")
+	}
+	if match.Path != "api/3.0/deliveryservices/{id}/capacity" {
+		t.Errorf("expected the {id} route to win, got %q", match.Path)
+	}
+	if params["id"] != "capacity" {
+		t.Errorf("expected captured param id=capacity, got %q", params["id"])
+	}
+
+	// The literal sibling route itself must still resolve correctly.
+	match = trie.match("api/3.0/deliveryservices/capacity/types", params)
+	if match == nil {
+		t.Fatal("expected the literal sibling route to still match")
+	}
+	if match.Path != "api/3.0/deliveryservices/capacity/types" {
+		t.Errorf("expected the literal route to win, got %q", match.Path)
+	}
+}
+
+func TestRouteTrieMatchTrailingSlash(t *testing.T) {
+	routes := buildTestCompiledRoutes()
+	trie := buildTestTrie(routes)
+	params := map[string]string{}
+
+	withSlash := trie.match("api/1.3/deliveryservices/42/", params)
+	withoutSlash := trie.match("api/1.3/deliveryservices/42", params)
+	if withSlash == nil || withoutSlash == nil {
+		t.Fatal("expected trailing slash and non-trailing slash paths to both match")
+	}
+	if withSlash.Path != withoutSlash.Path {
+		t.Errorf("expected trailing slash variant to resolve to the same route, got %q vs %q", withSlash.Path, withoutSlash.Path)
+	}
+}
+
+func FuzzRouteTrieMatch(f *testing.F) {
+	routes := buildTestCompiledRoutes()
+	trie := buildTestTrie(routes)
+
+	f.Add("api/1.3/deliveryservices/42")
+	f.Add("api/1.3/deliveryservices/42/")
+	f.Add("//api//1.3//deliveryservices//42//")
+	f.Add("api/1.3/deliveryservices/%2e%2e%2fsecret")
+	f.Add("")
+	f.Add("/")
+
+	f.Fuzz(func(t *testing.T, path string) {
+		params := map[string]string{}
+		// match must never panic, and any match it returns must have come from the route set
+		// it was built from.
+		match := trie.match(path, params)
+		if match == nil {
+			return
+		}
+		found := false
+		for _, r := range routes {
+			if r.Path == match.Path {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("match returned a route not present in the trie's source set: %q", match.Path)
+		}
+	})
+}
+
+func BenchmarkRouteDispatch_Trie(b *testing.B) {
+	routes := buildTestCompiledRoutes()
+	trie := buildTestTrie(routes)
+	params := map[string]string{}
+	path := "api/1.5/staticdnsentries/" + strconv.Itoa(7)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trie.match(path, params)
+	}
+}
+
+func BenchmarkRouteDispatch_RegexScan(b *testing.B) {
+	routes := compileRegexes(buildTestCompiledRoutes())
+	path := "api/1.5/staticdnsentries/" + strconv.Itoa(7)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		regexScanMatch(routes, path)
+	}
+}
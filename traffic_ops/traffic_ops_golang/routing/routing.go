@@ -56,6 +56,33 @@ type Route struct {
 	Middlewares       []Middleware
 	ID                int  // unique ID for referencing this Route
 	CanBypassToPerl   bool // if true, this Route can be passed through to Perl
+
+	// Timeout overrides the global RequestTimeout for this route. Zero means use the
+	// configured default. Handlers that routinely need more (or less) time than the rest of
+	// the API - bulk snapshots, cache-config generation, delivery-service updates - can set
+	// this instead of the global config affecting every other route.
+	Timeout time.Duration
+	// MaxBodyBytes overrides the default maximum request body size for this route. Zero means
+	// no per-route limit is applied beyond whatever the server already enforces. Enforced by
+	// getRouteMiddleware regardless of whether Middlewares is also set.
+	MaxBodyBytes int64
+
+	// DeprecatedIn is the first API version at which this route is considered deprecated.
+	// Zero means the route isn't deprecated.
+	DeprecatedIn float64
+	// RemovedIn is the API version at which this route is planned for removal. Zero means no
+	// removal is planned. ValidateRouteDeprecations fails startup if RemovedIn's major version
+	// is already the current major version being served, or an earlier one.
+	RemovedIn float64
+	// SunsetDate is the date after which clients should expect RemovedIn to actually return 404.
+	// Zero value means no sunset date has been set.
+	SunsetDate time.Time
+
+	// RateLimit overrides the globally-configured rate limit rules for this route. Nil means
+	// only the global rules (if any match) apply; expensive endpoints (snapshot, queue updates,
+	// CDN diff) can set their own stricter limit here. Enforced by getRouteMiddleware regardless
+	// of whether Middlewares is also set.
+	RateLimit *RateLimitSpec
 }
 
 func (r Route) String() string {
@@ -71,10 +98,68 @@ type RawRoute struct {
 	RequiredPrivLevel int
 	Authenticated     bool
 	Middlewares       []Middleware
+
+	// Timeout overrides the global RequestTimeout for this route. Zero means use the
+	// configured default.
+	Timeout time.Duration
+	// MaxBodyBytes overrides the default maximum request body size for this route. Zero means
+	// no per-route limit is applied beyond whatever the server already enforces. Enforced by
+	// getRouteMiddleware regardless of whether Middlewares is also set.
+	MaxBodyBytes int64
+}
+
+// effectiveTimeout returns routeTimeout if the route set one (Route.Timeout/RawRoute.Timeout),
+// else defaultTimeout.
+func effectiveTimeout(defaultTimeout, routeTimeout time.Duration) time.Duration {
+	if routeTimeout > 0 {
+		return routeTimeout
+	}
+	return defaultTimeout
 }
 
-func getDefaultMiddleware(secret string, requestTimeout time.Duration) []Middleware {
-	return []Middleware{getWrapAccessLog(secret), timeOutWrapper(requestTimeout), wrapHeaders, wrapPanicRecover}
+func getDefaultMiddleware(secret string, requestTimeout time.Duration, maxBodyBytes int64, useStructuredAccessLog bool, routeID int, routePath string, rateLimitMW Middleware, trustedProxies []string) []Middleware {
+	accessLog := getWrapAccessLog(secret)
+	if useStructuredAccessLog {
+		accessLog = getStructuredAccessLog(secret, routeID, trustedProxies)
+	}
+	middlewares := []Middleware{TracingMiddleware(routeID, routePath), accessLog, timeOutWrapper(requestTimeout), wrapHeaders, wrapPanicRecover}
+	if rateLimitMW != nil {
+		middlewares = append(middlewares, rateLimitMW)
+	}
+	if maxBodyBytes > 0 {
+		middlewares = append(middlewares, maxBodyBytesWrapper(maxBodyBytes))
+	}
+	return middlewares
+}
+
+// buildRateLimitMiddleware resolves the rate limit rule (if any) that applies to a route -
+// preferring its own override over the first matching rule from the global config - and
+// returns the Middleware enforcing it, or nil if no rule applies. secret is forwarded to
+// RateLimit so user/tenant keying can resolve identity straight from the request's session
+// cookie rather than depending on where this middleware sits relative to auth. trustedProxies
+// is forwarded to RateLimit so RateLimitKeyIP trusts X-Forwarded-For only from a configured
+// reverse proxy.
+func buildRateLimitMiddleware(backend RateLimitBackend, globalRules []RateLimitSpec, routeOverride *RateLimitSpec, method, routePath, secret string, trustedProxies []string) Middleware {
+	if backend == nil {
+		return nil
+	}
+	spec, ok := resolveRateLimit(routeOverride, globalRules, method, routePath)
+	if !ok {
+		return nil
+	}
+	return RateLimit(backend, spec, secret, trustedProxies)
+}
+
+// maxBodyBytesWrapper returns a Middleware that rejects request bodies larger than
+// maxBodyBytes, using http.MaxBytesReader so the limit is enforced as the body is read rather
+// than requiring the whole thing to be buffered up front.
+func maxBodyBytesWrapper(maxBodyBytes int64) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+			next(w, r)
+		}
+	}
 }
 
 // ServerData ...
@@ -90,6 +175,9 @@ type CompiledRoute struct {
 	Handler http.HandlerFunc
 	Regex   *regexp.Regexp
 	Params  []string
+	// Path is the route's original, pre-regex path template (e.g. "api/3.1/deliveryservices/{id}"),
+	// kept so the route can be indexed into a routeTrie without re-deriving segments from Regex.
+	Path string
 }
 
 func getSortedRouteVersions(rs []Route) []float64 {
@@ -113,7 +201,7 @@ type PathHandler struct {
 
 // CreateRouteMap returns a map of methods to a slice of paths and handlers; wrapping the handlers in the appropriate middleware. Uses Semantic Versioning: routes are added to every subsequent minor version, but not subsequent major versions. For example, a 1.2 route is added to 1.3 but not 2.1. Also truncates '2.0' to '2', creating succinct major versions.
 // Returns the map of routes, and a map of API versions served.
-func CreateRouteMap(rs []Route, rawRoutes []RawRoute, perlRouteIDs, disabledRouteIDs []int, perlHandler http.HandlerFunc, authBase AuthBase, reqTimeOutSeconds int) (map[string][]PathHandler, map[float64]struct{}) {
+func CreateRouteMap(rs []Route, rawRoutes []RawRoute, perlRouteIDs, disabledRouteIDs []int, perlHandler http.HandlerFunc, authBase AuthBase, reqTimeOutSeconds int, useStructuredAccessLog bool, rateLimitBackend RateLimitBackend, rateLimitRules []RateLimitSpec, trustedProxies []string) (map[string][]PathHandler, map[float64]struct{}) {
 	// TODO strong types for method, path
 	versions := getSortedRouteVersions(rs)
 	requestTimeout := time.Second * time.Duration(60)
@@ -128,26 +216,35 @@ func CreateRouteMap(rs []Route, rawRoutes []RawRoute, perlRouteIDs, disabledRout
 		nextMajorVer := float64(int(r.Version) + 1)
 		_, isPerlRoute := perlRoutes[r.ID]
 		_, isDisabledRoute := disabledRoutes[r.ID]
+		successorPath := successorVersionPath(versions[versionI:], nextMajorVer, r.Path)
 		for _, version := range versions[versionI:] {
 			if version >= nextMajorVer {
 				break
 			}
 			vstr := strconv.FormatFloat(version, 'f', -1, 64)
 			path := RoutePrefix + "/" + vstr + "/" + r.Path
-			middlewares := getRouteMiddleware(r.Middlewares, authBase, r.Authenticated, r.RequiredPrivLevel, requestTimeout)
+			routeTimeout := effectiveTimeout(requestTimeout, r.Timeout)
+			rateLimitMW := buildRateLimitMiddleware(rateLimitBackend, rateLimitRules, r.RateLimit, r.Method, r.Path, authBase.secret, trustedProxies)
+			middlewares := getRouteMiddleware(r.Middlewares, authBase, r.Authenticated, r.RequiredPrivLevel, routeTimeout, r.MaxBodyBytes, useStructuredAccessLog, r.ID, path, rateLimitMW, trustedProxies)
+			handler := r.Handler
+			if r.DeprecatedIn != 0 && version >= r.DeprecatedIn {
+				handler = deprecationMiddleware(r.RemovedIn, r.SunsetDate, successorPath)(handler)
+			}
 
 			if isPerlRoute {
 				m[r.Method] = append(m[r.Method], PathHandler{Path: path, Handler: perlHandler})
 			} else if isDisabledRoute {
 				m[r.Method] = append(m[r.Method], PathHandler{Path: path, Handler: wrapAccessLog(authBase.secret, DisabledRouteHandler())})
 			} else {
-				m[r.Method] = append(m[r.Method], PathHandler{Path: path, Handler: use(r.Handler, middlewares)})
+				m[r.Method] = append(m[r.Method], PathHandler{Path: path, Handler: use(handler, middlewares)})
 			}
 			log.Infof("adding route %v %v\n", r.Method, path)
 		}
 	}
 	for _, r := range rawRoutes {
-		middlewares := getRouteMiddleware(r.Middlewares, authBase, r.Authenticated, r.RequiredPrivLevel, requestTimeout)
+		rawRouteTimeout := effectiveTimeout(requestTimeout, r.Timeout)
+		rateLimitMW := buildRateLimitMiddleware(rateLimitBackend, rateLimitRules, nil, r.Method, r.Path, authBase.secret, trustedProxies)
+		middlewares := getRouteMiddleware(r.Middlewares, authBase, r.Authenticated, r.RequiredPrivLevel, rawRouteTimeout, r.MaxBodyBytes, useStructuredAccessLog, 0, r.Path, rateLimitMW, trustedProxies)
 		m[r.Method] = append(m[r.Method], PathHandler{Path: r.Path, Handler: use(r.Handler, middlewares)})
 		log.Infof("adding raw route %v %v\n", r.Method, r.Path)
 	}
@@ -160,9 +257,19 @@ func CreateRouteMap(rs []Route, rawRoutes []RawRoute, perlRouteIDs, disabledRout
 	return m, versionSet
 }
 
-func getRouteMiddleware(middlewares []Middleware, authBase AuthBase, authenticated bool, privLevel int, requestTimeout time.Duration) []Middleware {
+func getRouteMiddleware(middlewares []Middleware, authBase AuthBase, authenticated bool, privLevel int, requestTimeout time.Duration, maxBodyBytes int64, useStructuredAccessLog bool, routeID int, routePath string, rateLimitMW Middleware, trustedProxies []string) []Middleware {
 	if middlewares == nil {
-		middlewares = getDefaultMiddleware(authBase.secret, requestTimeout)
+		middlewares = getDefaultMiddleware(authBase.secret, requestTimeout, maxBodyBytes, useStructuredAccessLog, routeID, routePath, rateLimitMW, trustedProxies)
+	} else {
+		// A route that supplies its own Middlewares still needs its per-route rate limit and
+		// body size enforcement - those aren't optional protections a route should be able to
+		// opt out of just by customizing ordering elsewhere.
+		if rateLimitMW != nil {
+			middlewares = append(middlewares, rateLimitMW)
+		}
+		if maxBodyBytes > 0 {
+			middlewares = append(middlewares, maxBodyBytesWrapper(maxBodyBytes))
+		}
 	}
 	if authenticated { // a privLevel of zero is an unauthenticated endpoint.
 		authWrapper := authBase.GetWrapper(privLevel)
@@ -176,6 +283,9 @@ func CompileRoutes(routes map[string][]PathHandler) map[string][]CompiledRoute {
 	compiledRoutes := map[string][]CompiledRoute{}
 	for method, mRoutes := range routes {
 		for _, pathHandler := range mRoutes {
+			// Path carries the "^" regex anchor (see RoutePrefix); strip it for origPath since
+			// the trie matches path segments literally rather than via regex anchoring.
+			origPath := strings.TrimPrefix(pathHandler.Path, "^")
 			route := pathHandler.Path
 			handler := pathHandler.Handler
 			var params []string
@@ -190,7 +300,7 @@ func CompileRoutes(routes map[string][]PathHandler) map[string][]CompiledRoute {
 				route = route[:open] + `([^/]+)` + route[close+1:]
 			}
 			regex := regexp.MustCompile(route)
-			compiledRoutes[method] = append(compiledRoutes[method], CompiledRoute{Handler: handler, Regex: regex, Params: params})
+			compiledRoutes[method] = append(compiledRoutes[method], CompiledRoute{Handler: handler, Regex: regex, Params: params, Path: origPath})
 		}
 	}
 	return compiledRoutes
@@ -198,7 +308,7 @@ func CompileRoutes(routes map[string][]PathHandler) map[string][]CompiledRoute {
 
 // Handler - generic handler func used by the Handlers hooking into the routes
 func Handler(
-	routes map[string][]CompiledRoute,
+	tries map[string]*routeTrie,
 	versions map[float64]struct{},
 	catchall http.Handler,
 	db *sqlx.DB,
@@ -232,22 +342,20 @@ func Handler(
 	}
 
 	requested := r.URL.Path[1:]
-	mRoutes, ok := routes[r.Method]
+	trie, ok := tries[r.Method]
 	if !ok {
 		catchall.ServeHTTP(w, r)
 		return
 	}
 
-	for _, compiledRoute := range mRoutes {
-		match := compiledRoute.Regex.FindStringSubmatch(requested)
-		if len(match) == 0 {
-			continue
+	params := map[string]string{}
+	compiledRoute := trie.match(requested, params)
+	if compiledRoute == nil {
+		if negotiatedPath, ok := negotiateVersionedPath(r, requested, versions); ok {
+			compiledRoute = trie.match(negotiatedPath, params)
 		}
-		params := map[string]string{}
-		for i, v := range compiledRoute.Params {
-			params[v] = match[i+1]
-		}
-
+	}
+	if compiledRoute != nil {
 		routeCtx := context.WithValue(ctx, api.PathParamsKey, params)
 		r = r.WithContext(routeCtx)
 		compiledRoute.Handler(w, r)
@@ -286,19 +394,53 @@ func IsRequestAPIAndUnknownVersion(req *http.Request, versions map[float64]struc
 	return true // path starts with `/api`, and version is unknown
 }
 
+// tracingShutdown holds the shutdown func InitTracing returns from the most recent
+// RegisterRoutes call, retrievable via TracingShutdown. It's a package-level var rather than a
+// second RegisterRoutes return value so RegisterRoutes keeps its existing (ServerData) error
+// signature - RegisterRoutes has callers outside this change's series, and widening its return
+// shape would break every one of them without also updating their call sites.
+var tracingShutdown func(context.Context) error
+
+// TracingShutdown returns the shutdown func for the tracer InitTracing started during the most
+// recent RegisterRoutes call, or nil if RegisterRoutes hasn't run yet. With tracing disabled it
+// returns InitTracing's no-op shutdown func.
+// The caller should defer it so buffered spans are flushed before the process exits instead of
+// being dropped out of the OTLP/Jaeger batch buffer.
+func TracingShutdown() func(context.Context) error {
+	return tracingShutdown
+}
+
 // RegisterRoutes - parses the routes and registers the handlers with the Go Router
 func RegisterRoutes(d ServerData) error {
 	routeSlice, rawRoutes, catchall, err := Routes(d)
 	if err != nil {
 		return err
 	}
+	if err := ValidateRouteDeprecations(routeSlice); err != nil {
+		return err
+	}
+	rawRoutes = append(rawRoutes, RawRoute{
+		Method:        http.MethodGet,
+		Path:          RoutePrefix + `/{v}/deprecations`,
+		Handler:       DeprecationsHandler(routeSlice),
+		Authenticated: true,
+	})
+
+	shutdownTracing, err := InitTracing(d.Config.Tracing)
+	if err != nil {
+		return err
+	}
+	tracingShutdown = shutdownTracing
 
 	authBase := AuthBase{secret: d.Config.Secrets[0], override: nil} //we know d.Config.Secrets is a slice of at least one or start up would fail.
-	routes, versions := CreateRouteMap(routeSlice, rawRoutes, d.PerlRoutes, d.DisabledRoutes, handlerToFunc(catchall), authBase, d.RequestTimeout)
+	rateLimitBackend := newRateLimitBackend(d.Config.RateLimiting)
+	rateLimitRules := rateLimitSpecsFromConfig(d.Config.RateLimiting.Rules)
+	routes, versions := CreateRouteMap(routeSlice, rawRoutes, d.PerlRoutes, d.DisabledRoutes, handlerToFunc(catchall), authBase, d.RequestTimeout, d.Config.UseStructuredAccessLog, rateLimitBackend, rateLimitRules, d.Config.TrustedProxies)
 	compiledRoutes := CompileRoutes(routes)
+	tries := buildRouteTries(compiledRoutes)
 	getReqID := nextReqIDGetter()
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		Handler(compiledRoutes, versions, catchall, d.DB, &d.Config, getReqID, d.Plugins, w, r)
+		Handler(tries, versions, catchall, d.DB, &d.Config, getReqID, d.Plugins, w, r)
 	})
 	return nil
 }
@@ -0,0 +1,62 @@
+package routing
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestEffectiveTimeout(t *testing.T) {
+	if got := effectiveTimeout(60*time.Second, 0); got != 60*time.Second {
+		t.Errorf("expected the default to apply when the route sets no Timeout, got %v", got)
+	}
+	if got := effectiveTimeout(60*time.Second, 5*time.Second); got != 5*time.Second {
+		t.Errorf("expected the route's own Timeout to override the default, got %v", got)
+	}
+}
+
+func TestGetDefaultMiddlewareMaxBodyBytes(t *testing.T) {
+	withoutLimit := getDefaultMiddleware("secret", 60*time.Second, 0, false, 1, "cdns", nil, nil)
+	withLimit := getDefaultMiddleware("secret", 60*time.Second, 1024, false, 1, "cdns", nil, nil)
+
+	if len(withLimit) != len(withoutLimit)+1 {
+		t.Errorf("expected MaxBodyBytes>0 to append exactly one middleware, got %d vs %d", len(withLimit), len(withoutLimit))
+	}
+}
+
+// TestGetRouteMiddlewareCustomStillEnforcesLimits verifies that a route which supplies its own
+// Middlewares (opting out of getDefaultMiddleware) doesn't also opt out of its per-route
+// MaxBodyBytes and RateLimit enforcement.
+func TestGetRouteMiddlewareCustomStillEnforcesLimits(t *testing.T) {
+	custom := []Middleware{wrapHeaders}
+	rateLimitMW := func(next http.HandlerFunc) http.HandlerFunc { return next }
+
+	withoutOverrides := getRouteMiddleware(custom, AuthBase{}, false, 0, 60*time.Second, 0, false, 1, "cdns", nil, nil)
+	if len(withoutOverrides) != len(custom) {
+		t.Errorf("expected no extra middleware with no overrides set, got %d vs %d", len(withoutOverrides), len(custom))
+	}
+
+	withOverrides := getRouteMiddleware(custom, AuthBase{}, false, 0, 60*time.Second, 1024, false, 1, "cdns", rateLimitMW, nil)
+	if len(withOverrides) != len(custom)+2 {
+		t.Errorf("expected a custom Middlewares slice to still get MaxBodyBytes and RateLimit appended, got %d vs %d", len(withOverrides), len(custom))
+	}
+}
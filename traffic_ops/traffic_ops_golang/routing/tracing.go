@@ -0,0 +1,88 @@
+package routing
+
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/config"
+)
+
+// tracerName identifies this package's spans in whatever exporter (OTLP, Jaeger, or the no-op
+// default) InitTracing registered as the global TracerProvider at startup.
+const tracerName = "github.com/apache/trafficcontrol/traffic_ops/traffic_ops_golang/routing"
+
+// InitTracing configures the global OpenTelemetry TracerProvider from cfg, selecting between an
+// OTLP/HTTP exporter, a Jaeger exporter, or the SDK's no-op default. An empty cfg.Exporter (the
+// default) leaves the global no-op TracerProvider in place, so tracing being unconfigured is a
+// well-defined no-op rather than a startup failure. It returns a shutdown func that the caller
+// should defer so buffered spans are flushed on exit.
+func InitTracing(cfg config.TracingConfig) (func(context.Context) error, error) {
+	noop := func(context.Context) error { return nil }
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(cfg.Exporter) {
+	case "", "none":
+		return noop, nil
+	case "otlp":
+		exporter, err = otlptracehttp.New(context.Background(), otlptracehttp.WithEndpoint(cfg.OTLPEndpoint))
+	case "jaeger":
+		exporter, err = jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(cfg.JaegerEndpoint)))
+	default:
+		return nil, fmt.Errorf("tracing: unrecognized exporter %q (want \"otlp\", \"jaeger\", or \"\" for none)", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tracing: building %s exporter: %w", cfg.Exporter, err)
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// TracingMiddleware starts a server span for every request through the route it wraps, tagging
+// it with the matched route's id and path, and injects the resulting span context into
+// r.Context() before calling through so DB helpers and other downstream code can start child
+// spans. routeID is 0 for raw routes, which aren't tracked by ID.
+func TracingMiddleware(routeID int, routePath string) Middleware {
+	tracer := otel.Tracer(tracerName)
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := tracer.Start(r.Context(), routePath, trace.WithAttributes(
+				attribute.Int("route.id", routeID),
+				attribute.String("route.path", routePath),
+				attribute.String("http.method", r.Method),
+			))
+			defer span.End()
+			next(w, r.WithContext(ctx))
+		}
+	}
+}